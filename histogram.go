@@ -0,0 +1,142 @@
+package stats
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// latencyHistogramBuckets is the number of log-spaced buckets used to
+// approximate response time percentiles without retaining individual
+// samples.
+const latencyHistogramBuckets = 32
+
+// latencyHistogramMin and latencyHistogramMax bound the range covered by
+// the histogram. Observations outside the range are clamped into the
+// first or last bucket.
+const (
+	latencyHistogramMin = time.Millisecond
+	latencyHistogramMax = 60 * time.Second
+)
+
+// latencyHistogramBounds holds the lower bound of each bucket. Bucket i
+// covers [bounds[i], bounds[i+1]), with the last bucket open-ended at
+// latencyHistogramMax.
+var latencyHistogramBounds = buildLatencyHistogramBounds()
+
+func buildLatencyHistogramBounds() [latencyHistogramBuckets]time.Duration {
+	var bounds [latencyHistogramBuckets]time.Duration
+	ratio := math.Pow(float64(latencyHistogramMax)/float64(latencyHistogramMin), 1/float64(latencyHistogramBuckets-1))
+	v := float64(latencyHistogramMin)
+	for i := range bounds {
+		bounds[i] = time.Duration(v)
+		v *= ratio
+	}
+	return bounds
+}
+
+// latencyHistogram is a fixed-bucket, log-spaced histogram of response
+// times. It is updated on every observation in O(log n) time and never
+// retains individual samples, which keeps its memory footprint constant
+// regardless of traffic volume.
+type latencyHistogram struct {
+	counts [latencyHistogramBuckets]uint64
+	total  uint64
+	sum    time.Duration
+}
+
+// histogramSnapshot is the JSON-serializable form of latencyHistogram's
+// otherwise-unexported state, used by Snapshot/Restore to persist
+// histogram data (and therefore accurate percentiles) across restarts.
+type histogramSnapshot struct {
+	Counts [latencyHistogramBuckets]uint64 `json:"counts"`
+	Total  uint64                          `json:"total"`
+	Sum    time.Duration                   `json:"sum"`
+}
+
+func (h *latencyHistogram) snapshot() histogramSnapshot {
+	return histogramSnapshot{Counts: h.counts, Total: h.total, Sum: h.sum}
+}
+
+func (s histogramSnapshot) restore() latencyHistogram {
+	return latencyHistogram{counts: s.Counts, total: s.Total, sum: s.Sum}
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	idx := sort.Search(latencyHistogramBuckets, func(i int) bool {
+		return latencyHistogramBounds[i] > d
+	}) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	h.counts[idx]++
+	h.total++
+	h.sum += d
+}
+
+// merge folds other's observations into h, bucket by bucket. Both
+// histograms must share the same bucket bounds, which is always true
+// since latencyHistogramBounds is fixed package-wide.
+func (h *latencyHistogram) merge(other latencyHistogram) {
+	for i := range h.counts {
+		h.counts[i] += other.counts[i]
+	}
+	h.total += other.total
+	h.sum += other.sum
+}
+
+// cumulativeBuckets returns the histogram's finite buckets in the
+// cumulative form Prometheus/OpenMetrics expects: upperBounds[i] is the
+// "le" threshold of bucket i and cumulative[i] is the count of
+// observations below upperBounds[i] (an observation exactly equal to a
+// bound is counted in the next bucket). The last bucket (everything >=
+// latencyHistogramMax) has no finite upper bound; callers exposing this
+// to Prometheus should additionally emit a "+Inf" bucket equal to total.
+func (h *latencyHistogram) cumulativeBuckets() (upperBounds []time.Duration, cumulative []uint64) {
+	upperBounds = make([]time.Duration, latencyHistogramBuckets-1)
+	cumulative = make([]uint64, latencyHistogramBuckets-1)
+
+	var running uint64
+	for i := 0; i < latencyHistogramBuckets-1; i++ {
+		running += h.counts[i]
+		cumulative[i] = running
+		upperBounds[i] = latencyHistogramBounds[i+1]
+	}
+
+	return upperBounds, cumulative
+}
+
+// percentile returns an estimate of the p-th percentile (0 <= p <= 1) of
+// the observed response times, linearly interpolating within the bucket
+// that contains it.
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	target := uint64(math.Ceil(p * float64(h.total)))
+	if target == 0 {
+		target = 1
+	}
+
+	var cumulative uint64
+	for i, c := range h.counts {
+		cumulative += c
+		if cumulative < target {
+			continue
+		}
+		if c == 0 {
+			return latencyHistogramBounds[i]
+		}
+
+		lower := latencyHistogramBounds[i]
+		upper := latencyHistogramMax
+		if i+1 < latencyHistogramBuckets {
+			upper = latencyHistogramBounds[i+1]
+		}
+		fraction := float64(target-(cumulative-c)) / float64(c)
+		return lower + time.Duration(fraction*float64(upper-lower))
+	}
+
+	return latencyHistogramMax
+}
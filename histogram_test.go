@@ -0,0 +1,153 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogramPercentile(t *testing.T) {
+	tests := []struct {
+		name       string
+		observe    []time.Duration
+		percentile float64
+		want       time.Duration
+		tolerance  time.Duration
+	}{
+		{
+			name:       "empty histogram",
+			observe:    nil,
+			percentile: 0.99,
+			want:       0,
+		},
+		{
+			// A single observation can only be pinned down to the bucket
+			// it falls in; percentile reports that bucket's upper bound
+			// as the worst-case estimate.
+			name:       "single observation is bounded by its bucket",
+			observe:    []time.Duration{50 * time.Millisecond},
+			percentile: 0.50,
+			want:       latencyHistogramBounds[bucketFor(50*time.Millisecond)+1],
+			tolerance:  bucketWidth(bucketFor(50 * time.Millisecond)),
+		},
+		{
+			name:       "uniform 0-999ms load estimates p99 near 1s",
+			observe:    uniformMillis(0, 999),
+			percentile: 0.99,
+			want:       990 * time.Millisecond,
+			tolerance:  250 * time.Millisecond,
+		},
+		{
+			name:       "uniform 0-999ms load estimates p50 near 500ms",
+			observe:    uniformMillis(0, 999),
+			percentile: 0.50,
+			want:       500 * time.Millisecond,
+			tolerance:  100 * time.Millisecond,
+		},
+		{
+			name:       "observation above max is clamped into the last bucket",
+			observe:    []time.Duration{5 * time.Minute},
+			percentile: 0.99,
+			want:       latencyHistogramMax,
+			tolerance:  latencyHistogramMax - latencyHistogramBounds[latencyHistogramBuckets-1],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h latencyHistogram
+			for _, d := range tt.observe {
+				h.observe(d)
+			}
+
+			got := h.percentile(tt.percentile)
+			if diff := got - tt.want; diff < -tt.tolerance || diff > tt.tolerance {
+				t.Errorf("percentile(%v) = %v, want %v ± %v", tt.percentile, got, tt.want, tt.tolerance)
+			}
+		})
+	}
+}
+
+func TestLatencyHistogramMerge(t *testing.T) {
+	var a, b latencyHistogram
+	for _, d := range uniformMillis(0, 99) {
+		a.observe(d)
+	}
+	for _, d := range uniformMillis(900, 999) {
+		b.observe(d)
+	}
+
+	combined := a
+	combined.merge(b)
+
+	wantTotal := a.total + b.total
+	if combined.total != wantTotal {
+		t.Fatalf("merged total = %d, want %d", combined.total, wantTotal)
+	}
+	if combined.sum != a.sum+b.sum {
+		t.Fatalf("merged sum = %v, want %v", combined.sum, a.sum+b.sum)
+	}
+
+	// The merged histogram should see observations from both ranges, so
+	// its p99 must be pulled up towards b's range rather than staying
+	// near a's.
+	if p99 := combined.percentile(0.99); p99 < 800*time.Millisecond {
+		t.Errorf("merged p99 = %v, want at least 800ms", p99)
+	}
+}
+
+func TestLatencyHistogramCumulativeBucketsNoDuplicateFinalBound(t *testing.T) {
+	var h latencyHistogram
+	for _, d := range uniformMillis(0, 999) {
+		h.observe(d)
+	}
+
+	upperBounds, cumulative := h.cumulativeBuckets()
+	if len(upperBounds) != latencyHistogramBuckets-1 {
+		t.Fatalf("got %d finite buckets, want %d", len(upperBounds), latencyHistogramBuckets-1)
+	}
+	if len(cumulative) != len(upperBounds) {
+		t.Fatalf("cumulative has %d entries, want %d", len(cumulative), len(upperBounds))
+	}
+	if cumulative[len(cumulative)-1] > h.total {
+		t.Fatalf("last cumulative bucket %d exceeds total %d", cumulative[len(cumulative)-1], h.total)
+	}
+}
+
+func bucketFor(d time.Duration) int {
+	var h latencyHistogram
+	h.observe(d)
+	for i, c := range h.counts {
+		if c > 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+func bucketWidth(idx int) time.Duration {
+	upper := latencyHistogramMax
+	if idx+1 < latencyHistogramBuckets {
+		upper = latencyHistogramBounds[idx+1]
+	}
+	return upper - latencyHistogramBounds[idx]
+}
+
+func uniformMillis(lo, hi int) []time.Duration {
+	out := make([]time.Duration, 0, hi-lo+1)
+	for i := lo; i <= hi; i++ {
+		out = append(out, time.Duration(i)*time.Millisecond)
+	}
+	return out
+}
+
+func TestHistogramSnapshotRoundTrip(t *testing.T) {
+	var h latencyHistogram
+	for _, d := range uniformMillis(0, 999) {
+		h.observe(d)
+	}
+
+	restored := h.snapshot().restore()
+	if restored.total != h.total || restored.sum != h.sum || restored.counts != h.counts {
+		t.Fatalf("restored histogram = %+v, want %+v", restored, h)
+	}
+}
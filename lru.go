@@ -0,0 +1,43 @@
+package stats
+
+import "container/list"
+
+// routeLRU tracks which routes have been seen most recently so that,
+// once more than maxRoutes distinct routes have been seen, the least
+// recently seen one can be evicted to keep memory bounded under high-cardinality
+// routes (even after GetKey normalization).
+type routeLRU struct {
+	maxRoutes int
+	order     *list.List
+	elems     map[string]*list.Element
+}
+
+func newRouteLRU(maxRoutes int) *routeLRU {
+	return &routeLRU{
+		maxRoutes: maxRoutes,
+		order:     list.New(),
+		elems:     map[string]*list.Element{},
+	}
+}
+
+// touch marks route as most recently seen. If route is new and seeing
+// it pushed the tracked set past maxRoutes, touch reports the route
+// that should now be evicted.
+func (l *routeLRU) touch(route string) (evict string, shouldEvict bool) {
+	if e, ok := l.elems[route]; ok {
+		l.order.MoveToFront(e)
+		return "", false
+	}
+
+	l.elems[route] = l.order.PushFront(route)
+
+	if l.maxRoutes > 0 && l.order.Len() > l.maxRoutes {
+		back := l.order.Back()
+		evict = back.Value.(string)
+		l.order.Remove(back)
+		delete(l.elems, evict)
+		shouldEvict = true
+	}
+
+	return evict, shouldEvict
+}
@@ -0,0 +1,74 @@
+package stats
+
+import "testing"
+
+func TestRouteLRUTouch(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRoutes  int
+		touches    []string
+		wantEvict  string
+		wantEvicts bool
+	}{
+		{
+			name:      "unlimited never evicts",
+			maxRoutes: 0,
+			touches:   []string{"/a", "/b", "/c", "/d"},
+		},
+		{
+			name:       "evicts least recently seen once over cap",
+			maxRoutes:  2,
+			touches:    []string{"/a", "/b", "/c"},
+			wantEvict:  "/a",
+			wantEvicts: true,
+		},
+		{
+			name:      "re-touching an existing route doesn't evict",
+			maxRoutes: 2,
+			touches:   []string{"/a", "/b", "/a", "/b"},
+		},
+		{
+			name:       "re-touching protects a route from eviction",
+			maxRoutes:  2,
+			touches:    []string{"/a", "/b", "/a", "/c"},
+			wantEvict:  "/b",
+			wantEvicts: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRouteLRU(tt.maxRoutes)
+
+			var evict string
+			var shouldEvict bool
+			for _, route := range tt.touches {
+				evict, shouldEvict = l.touch(route)
+			}
+
+			if shouldEvict != tt.wantEvicts {
+				t.Fatalf("shouldEvict = %v, want %v", shouldEvict, tt.wantEvicts)
+			}
+			if shouldEvict && evict != tt.wantEvict {
+				t.Fatalf("evicted %q, want %q", evict, tt.wantEvict)
+			}
+		})
+	}
+}
+
+func TestRouteLRUEvictsOnlyOncePerOverflow(t *testing.T) {
+	l := newRouteLRU(1)
+
+	if _, shouldEvict := l.touch("/a"); shouldEvict {
+		t.Fatalf("touching the first route under cap should not evict")
+	}
+
+	evict, shouldEvict := l.touch("/b")
+	if !shouldEvict || evict != "/a" {
+		t.Fatalf("touch(/b) = (%q, %v), want (\"/a\", true)", evict, shouldEvict)
+	}
+
+	if _, shouldEvict := l.touch("/b"); shouldEvict {
+		t.Fatalf("re-touching the only tracked route should not evict")
+	}
+}
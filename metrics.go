@@ -0,0 +1,122 @@
+package stats
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Handler returns an http.Handler that serves the collected counters in
+// Prometheus/OpenMetrics text exposition format, so they can be scraped
+// directly instead of polled through Data.
+func (mw *Stats) Handler() http.Handler {
+	return http.HandlerFunc(mw.serveMetrics)
+}
+
+// PrometheusHandler is Handler under the name most callers will look
+// for when wiring up a scrape target.
+func (mw *Stats) PrometheusHandler() http.Handler {
+	return mw.Handler()
+}
+
+func (mw *Stats) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	mw.mu.RLock()
+	defer mw.mu.RUnlock()
+
+	var b strings.Builder
+
+	writeRequestsTotal(&b, mw.requestsByStatus)
+	writeSimpleCounter(&b, "http_requests_timeouts_total", "Total number of HTTP requests that exceeded the configured timeout threshold.", mw.TimeoutRoutes)
+	writeSlowRoutesTotal(&b, mw.SlowRoutes)
+	writeRequestDuration(&b, mw.latenciesByRoute)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+func writeRequestsTotal(b *strings.Builder, counts map[statusLabel]int64) {
+	fmt.Fprintln(b, "# HELP http_requests_total Total number of HTTP requests processed.")
+	fmt.Fprintln(b, "# TYPE http_requests_total counter")
+
+	labels := make([]statusLabel, 0, len(counts))
+	for l := range counts {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labelKey(labels[i]) < labelKey(labels[j])
+	})
+
+	for _, l := range labels {
+		fmt.Fprintf(b, "http_requests_total{route=%q,method=%q,status=%q} %d\n", l.Route, l.Method, fmt.Sprintf("%d", l.Status), counts[l])
+	}
+}
+
+func writeSimpleCounter(b *strings.Builder, name, help string, counts map[string]int) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s counter\n", name)
+
+	routes := make([]string, 0, len(counts))
+	for route := range counts {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	for _, route := range routes {
+		fmt.Fprintf(b, "%s{route=%q} %d\n", name, route, counts[route])
+	}
+}
+
+func writeSlowRoutesTotal(b *strings.Builder, slowRoutes map[string]SlowRoutesData) {
+	const name = "http_requests_slow_total"
+	fmt.Fprintf(b, "# HELP %s Total number of HTTP requests slower than the configured latency threshold.\n", name)
+	fmt.Fprintf(b, "# TYPE %s counter\n", name)
+
+	routes := make([]string, 0, len(slowRoutes))
+	for route := range slowRoutes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	for _, route := range routes {
+		fmt.Fprintf(b, "%s{route=%q} %d\n", name, route, slowRoutes[route].Count)
+	}
+}
+
+func writeRequestDuration(b *strings.Builder, histograms map[routeLabel]*latencyHistogram) {
+	const name = "http_request_duration_seconds"
+	fmt.Fprintf(b, "# HELP %s HTTP request duration in seconds.\n", name)
+	fmt.Fprintf(b, "# TYPE %s histogram\n", name)
+
+	labels := make([]routeLabel, 0, len(histograms))
+	for l := range histograms {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return routeLabelKey(labels[i]) < routeLabelKey(labels[j])
+	})
+
+	for _, l := range labels {
+		hist := histograms[l]
+		upperBounds, cumulative := hist.cumulativeBuckets()
+		for i, ub := range upperBounds {
+			fmt.Fprintf(b, "%s_bucket{route=%q,method=%q,le=%q} %d\n", name, l.Route, l.Method, formatSeconds(ub), cumulative[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{route=%q,method=%q,le=\"+Inf\"} %d\n", name, l.Route, l.Method, hist.total)
+		fmt.Fprintf(b, "%s_sum{route=%q,method=%q} %g\n", name, l.Route, l.Method, hist.sum.Seconds())
+		fmt.Fprintf(b, "%s_count{route=%q,method=%q} %d\n", name, l.Route, l.Method, hist.total)
+	}
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%g", d.Seconds())
+}
+
+func routeLabelKey(l routeLabel) string {
+	return l.Route + "\x1f" + l.Method
+}
+
+func labelKey(l statusLabel) string {
+	return routeLabelKey(l.routeLabel) + "\x1f" + fmt.Sprintf("%d", l.Status)
+}
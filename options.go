@@ -0,0 +1,136 @@
+package stats
+
+import (
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Option configures a Stats instance built with NewWithOptions.
+type Option func(*Stats)
+
+// WithLogger sets the logger Stats uses to report panics recovered in
+// ServeHTTP.
+func WithLogger(logger *log.Logger) Option {
+	return func(s *Stats) {
+		s.Logger = logger
+	}
+}
+
+// WithLatencyThreshold sets the response time above which a request is
+// recorded in SlowRoutes.
+func WithLatencyThreshold(d time.Duration) Option {
+	return func(s *Stats) {
+		s.Latency = d
+	}
+}
+
+// WithTimeoutThreshold sets the response time above which a request is
+// recorded in TimeoutRoutes instead of SlowRoutes.
+func WithTimeoutThreshold(d time.Duration) Option {
+	return func(s *Stats) {
+		s.TimeoutLimit = d
+	}
+}
+
+// WithKeyFunc sets the function used to normalize a request's path and
+// method into the route key used to group statistics (for example,
+// collapsing "/users/42" and "/users/43" into "/users/:id").
+func WithKeyFunc(fn reformatURL) Option {
+	return func(s *Stats) {
+		s.GetKey = fn
+	}
+}
+
+// WithClock overrides the clock Stats uses to measure request start and
+// end times. It defaults to time.Now and mainly exists for tests that
+// need deterministic timing.
+func WithClock(clock func() time.Time) Option {
+	return func(s *Stats) {
+		s.clock = clock
+	}
+}
+
+// WithMaxRoutes caps the number of distinct routes tracked in
+// RouteStats. When a new route would push the count past n, the least
+// recently seen route is evicted and its counters are folded into the
+// "other" bucket, keeping
+// memory bounded under high-cardinality routes (even after GetKey
+// normalization). n <= 0 (the default) means unlimited.
+func WithMaxRoutes(n int) Option {
+	return func(s *Stats) {
+		s.maxRoutes = n
+	}
+}
+
+// WithSampleRate records only a fraction f (0 < f <= 1) of requests
+// into the per-route latency histogram backing RouteStats' percentiles,
+// while still counting every request towards Count, Bytes and the
+// status breakdown. Use it to keep the histogram path cheap under very
+// high traffic. The default, 1, samples every request.
+func WithSampleRate(f float64) Option {
+	return func(s *Stats) {
+		s.sampleRate = f
+	}
+}
+
+// newStats builds a Stats from opts without starting any background
+// goroutines, so callers that construct a Stats they never intend to
+// Close (the legacy New constructor) don't leak one.
+func newStats(opts ...Option) *Stats {
+	s := &Stats{
+		Uptime:            time.Now(),
+		Pid:               os.Getpid(),
+		ResponseCounts:    map[string]int{},
+		TotalResponseTime: time.Time{},
+		BadRoutes:         map[string]int{},
+		SlowRoutes:        map[string]SlowRoutesData{},
+		TimeoutRoutes:     map[string]int{},
+		requestsByStatus:  map[statusLabel]int64{},
+		latenciesByRoute:  map[routeLabel]*latencyHistogram{},
+		RouteStats:        map[string]RouteStats{},
+		sampleRate:        1,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.routeLRU = newRouteLRU(s.maxRoutes)
+
+	return s
+}
+
+// NewWithOptions constructs a Stats using functional options, e.g.:
+//
+//	stats.NewWithOptions(
+//		stats.WithLogger(logger),
+//		stats.WithLatencyThreshold(200*time.Millisecond),
+//		stats.WithTimeoutThreshold(5*time.Second),
+//	)
+//
+// Any field not set by an option falls back to its zero value, matching
+// the behavior of New. Unlike New, it starts the rate calculator (and,
+// if WithSnapshotFile is set, the snapshot writer) in the background;
+// callers should call Close when done with the returned Stats to stop
+// them.
+func NewWithOptions(opts ...Option) *Stats {
+	s := newStats(opts...)
+
+	if s.snapshotFile != "" {
+		s.loadSnapshotFile()
+		if s.snapshotInterval > 0 {
+			s.snapshotStop = make(chan struct{})
+			s.snapshotDone = make(chan struct{})
+			go s.runSnapshotLoop()
+		}
+	}
+
+	s.rate = newRateCalculator()
+	go s.rate.run(func() (count, errors int64) {
+		return atomic.LoadInt64(&s.totalCount), atomic.LoadInt64(&s.totalErrors)
+	})
+
+	return s
+}
@@ -0,0 +1,130 @@
+package stats
+
+import (
+	"sync"
+	"time"
+)
+
+// rateSampleInterval is how often the background rate calculator
+// samples the cumulative request and error counters.
+const rateSampleInterval = time.Second
+
+// rateWindows are the lookback windows reported in RPS and ErrorRPS, in
+// the same order those slices are returned in.
+var rateWindows = []time.Duration{
+	10 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	60 * time.Minute,
+}
+
+type rateSample struct {
+	at     time.Time
+	count  int64
+	errors int64
+}
+
+// rateCalculator periodically samples cumulative request/error counts
+// and retains enough history to compute moving rates over rateWindows,
+// in the spirit of syncthing's relay rateCalculator. It turns Stats
+// from a pure lifetime-cumulative counter into something usable for
+// live dashboards and alerting.
+type rateCalculator struct {
+	mu      sync.Mutex
+	samples []rateSample
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newRateCalculator() *rateCalculator {
+	return &rateCalculator{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// run samples sample() every rateSampleInterval until stopAndWait is
+// called. It's meant to be run in its own goroutine.
+func (rc *rateCalculator) run(sample func() (count, errors int64)) {
+	defer close(rc.done)
+
+	ticker := time.NewTicker(rateSampleInterval)
+	defer ticker.Stop()
+
+	maxWindow := rateWindows[len(rateWindows)-1]
+
+	for {
+		select {
+		case <-rc.stop:
+			return
+		case now := <-ticker.C:
+			count, errors := sample()
+
+			rc.mu.Lock()
+			rc.samples = append(rc.samples, rateSample{at: now, count: count, errors: errors})
+			cutoff := now.Add(-maxWindow - rateSampleInterval)
+			i := 0
+			for i < len(rc.samples) && rc.samples[i].at.Before(cutoff) {
+				i++
+			}
+			rc.samples = rc.samples[i:]
+			rc.mu.Unlock()
+		}
+	}
+}
+
+func (rc *rateCalculator) stopAndWait() {
+	close(rc.stop)
+	<-rc.done
+}
+
+// rates returns the request-per-second and error-per-second rate for
+// each entry in rateWindows. A window with insufficient history (the
+// calculator hasn't been running long enough) reports 0.
+func (rc *rateCalculator) rates() (rps, errorRps []float64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rps = make([]float64, len(rateWindows))
+	errorRps = make([]float64, len(rateWindows))
+
+	if len(rc.samples) == 0 {
+		return rps, errorRps
+	}
+
+	latest := rc.samples[len(rc.samples)-1]
+	oldest := rc.samples[0]
+
+	for i, window := range rateWindows {
+		target := latest.at.Add(-window)
+		if oldest.at.After(target) {
+			// Not enough history yet to cover the full window; reporting
+			// a rate using the oldest sample as a stand-in base would
+			// understate the window, so leave it at the zero value.
+			continue
+		}
+
+		base, ok := rateSample{}, false
+		for _, s := range rc.samples {
+			if !s.at.Before(target) {
+				base, ok = s, true
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		elapsed := latest.at.Sub(base.at).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		rps[i] = float64(latest.count-base.count) / elapsed
+		errorRps[i] = float64(latest.errors-base.errors) / elapsed
+	}
+
+	return rps, errorRps
+}
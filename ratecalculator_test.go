@@ -0,0 +1,82 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateCalculatorRates(t *testing.T) {
+	start := time.Unix(0, 0)
+
+	tests := []struct {
+		name        string
+		samples     []rateSample
+		wantRPS     []float64
+		wantErrRPS  []float64
+		windowIndex int // index into rateWindows to assert, for readability
+	}{
+		{
+			name:        "no samples reports zero for every window",
+			samples:     nil,
+			windowIndex: 0,
+			wantRPS:     []float64{0},
+			wantErrRPS:  []float64{0},
+		},
+		{
+			name: "window shorter than available history computes a rate",
+			samples: []rateSample{
+				{at: start, count: 0, errors: 0},
+				{at: start.Add(10 * time.Second), count: 100, errors: 10},
+			},
+			windowIndex: 0, // 10s window
+			wantRPS:     []float64{10},
+			wantErrRPS:  []float64{1},
+		},
+		{
+			name: "window longer than available history reports 0, not a rate over partial history",
+			samples: []rateSample{
+				{at: start, count: 0, errors: 0},
+				{at: start.Add(10 * time.Second), count: 100, errors: 10},
+			},
+			windowIndex: 1, // 1m window, only 10s of history exists
+			wantRPS:     []float64{0},
+			wantErrRPS:  []float64{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &rateCalculator{samples: tt.samples}
+
+			rps, errorRps := rc.rates()
+
+			if got := rps[tt.windowIndex]; got != tt.wantRPS[0] {
+				t.Errorf("rps[%d] = %v, want %v", tt.windowIndex, got, tt.wantRPS[0])
+			}
+			if got := errorRps[tt.windowIndex]; got != tt.wantErrRPS[0] {
+				t.Errorf("errorRps[%d] = %v, want %v", tt.windowIndex, got, tt.wantErrRPS[0])
+			}
+		})
+	}
+}
+
+func TestRateCalculatorRatesExactlyCoveredWindow(t *testing.T) {
+	start := time.Unix(0, 0)
+	rc := &rateCalculator{
+		samples: []rateSample{
+			{at: start, count: 0, errors: 0},
+			{at: start.Add(rateWindows[0]), count: 50, errors: 5},
+		},
+	}
+
+	rps, errorRps := rc.rates()
+
+	wantRPS := 50 / rateWindows[0].Seconds()
+	if rps[0] != wantRPS {
+		t.Errorf("rps[0] = %v, want %v", rps[0], wantRPS)
+	}
+	wantErrRPS := 5 / rateWindows[0].Seconds()
+	if errorRps[0] != wantErrRPS {
+		t.Errorf("errorRps[0] = %v, want %v", errorRps[0], wantErrRPS)
+	}
+}
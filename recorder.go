@@ -0,0 +1,61 @@
+package stats
+
+import "net/http"
+
+// Recorder is implemented by response writers that track the status
+// code and size of the response they write, so that Stats can inspect
+// them once a handler has finished.
+type Recorder interface {
+	http.ResponseWriter
+	Status() int
+	Size() int
+}
+
+// RecorderResponseWriter wraps an http.ResponseWriter and records the
+// status code passed to WriteHeader and the number of bytes written.
+type RecorderResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *RecorderResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *RecorderResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *RecorderResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *RecorderResponseWriter) Size() int {
+	return w.size
+}
+
+// NewRecorder wraps w in a Recorder. Frameworks that don't run through
+// ServeHTTP (net/http, chi, gin, fasthttp's compatibility shims, ...)
+// can use it to build the Options value End expects:
+//
+//	rec := stats.NewRecorder(w)
+//	start := time.Now()
+//	next.ServeHTTP(rec, r)
+//	mw.End(start, stats.Options{Route: route, StatusCode: rec.Status(), Size: rec.Size()})
+func NewRecorder(w http.ResponseWriter) Recorder {
+	return &RecorderResponseWriter{w, http.StatusOK, 0}
+}
+
+// Options describes the outcome of a single request, for callers that
+// report it directly to End instead of going through the Negroni
+// ServeHTTP middleware chain.
+type Options struct {
+	Route      string
+	Method     string
+	StatusCode int
+	Size       int
+}
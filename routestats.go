@@ -0,0 +1,146 @@
+package stats
+
+import "time"
+
+// otherRouteKey aggregates routes evicted by the WithMaxRoutes cap, so
+// traffic to long-tail or unbounded-cardinality routes is still counted
+// somewhere instead of silently dropped.
+const otherRouteKey = "other"
+
+// RouteStats is a per-route breakdown of response counts by status
+// class, request count, response size, and latency percentiles. It
+// supersedes the coarser global ResponseCounts/BadRoutes for callers
+// that need per-route detail.
+type RouteStats struct {
+	Count int
+	Bytes int64
+
+	Status2xx int
+	Status3xx int
+	Status4xx int
+	Status5xx int
+
+	// Status429, Status499 and Status503 break out codes that are
+	// commonly alerted on individually (rate limiting, client
+	// disconnects, and backend unavailability).
+	Status429 int
+	Status499 int
+	Status503 int
+
+	P50 time.Duration
+	P90 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+
+	histogram latencyHistogram
+}
+
+func (rs *RouteStats) record(status, size int, responseTime time.Duration, sampled bool) {
+	rs.Count++
+	rs.Bytes += int64(size)
+
+	switch {
+	case status >= 200 && status < 300:
+		rs.Status2xx++
+	case status >= 300 && status < 400:
+		rs.Status3xx++
+	case status >= 400 && status < 500:
+		rs.Status4xx++
+	case status >= 500:
+		rs.Status5xx++
+	}
+
+	switch status {
+	case 429:
+		rs.Status429++
+	case 499:
+		rs.Status499++
+	case 503:
+		rs.Status503++
+	}
+
+	if sampled {
+		rs.histogram.observe(responseTime)
+		rs.P50 = rs.histogram.percentile(0.50)
+		rs.P90 = rs.histogram.percentile(0.90)
+		rs.P95 = rs.histogram.percentile(0.95)
+		rs.P99 = rs.histogram.percentile(0.99)
+	}
+}
+
+// mergeInto folds rs's counters into the route stats for otherRouteKey,
+// used when WithMaxRoutes evicts a least-recently-seen route.
+func (rs RouteStats) mergeInto(other *RouteStats) {
+	other.Count += rs.Count
+	other.Bytes += rs.Bytes
+	other.Status2xx += rs.Status2xx
+	other.Status3xx += rs.Status3xx
+	other.Status4xx += rs.Status4xx
+	other.Status5xx += rs.Status5xx
+	other.Status429 += rs.Status429
+	other.Status499 += rs.Status499
+	other.Status503 += rs.Status503
+	other.histogram.merge(rs.histogram)
+	other.P50 = other.histogram.percentile(0.50)
+	other.P90 = other.histogram.percentile(0.90)
+	other.P95 = other.histogram.percentile(0.95)
+	other.P99 = other.histogram.percentile(0.99)
+}
+
+// evictRouteToOther removes every per-route entry WithMaxRoutes tracks
+// for route and folds it into otherRouteKey, across all of RouteStats,
+// BadRoutes, SlowRoutes, TimeoutRoutes, requestsByStatus and
+// latenciesByRoute. Folding all of them, not just RouteStats, is what
+// keeps memory bounded under the high-cardinality routes the cap exists
+// for. Called with mw.mu already held.
+func (mw *Stats) evictRouteToOther(route string) {
+	if rs, ok := mw.RouteStats[route]; ok {
+		delete(mw.RouteStats, route)
+		other := mw.RouteStats[otherRouteKey]
+		rs.mergeInto(&other)
+		mw.RouteStats[otherRouteKey] = other
+	}
+
+	if n, ok := mw.BadRoutes[route]; ok {
+		delete(mw.BadRoutes, route)
+		mw.BadRoutes[otherRouteKey] += n
+	}
+
+	if n, ok := mw.TimeoutRoutes[route]; ok {
+		delete(mw.TimeoutRoutes, route)
+		mw.TimeoutRoutes[otherRouteKey] += n
+	}
+
+	if srd, ok := mw.SlowRoutes[route]; ok {
+		delete(mw.SlowRoutes, route)
+		other := mw.SlowRoutes[otherRouteKey]
+		mergeSlowRoutesData(&other, srd)
+		mw.SlowRoutes[otherRouteKey] = other
+	}
+
+	// requestsByStatus and latenciesByRoute are keyed by route+method, so
+	// a single route can own several entries across methods; fold all of
+	// them into the "other" bucket for their respective method.
+	for label, count := range mw.requestsByStatus {
+		if label.Route != route {
+			continue
+		}
+		delete(mw.requestsByStatus, label)
+		label.Route = otherRouteKey
+		mw.requestsByStatus[label] += count
+	}
+
+	for label, hist := range mw.latenciesByRoute {
+		if label.Route != route {
+			continue
+		}
+		delete(mw.latenciesByRoute, label)
+		otherLabel := routeLabel{Route: otherRouteKey, Method: label.Method}
+		otherHist, ok := mw.latenciesByRoute[otherLabel]
+		if !ok {
+			otherHist = &latencyHistogram{}
+			mw.latenciesByRoute[otherLabel] = otherHist
+		}
+		otherHist.merge(*hist)
+	}
+}
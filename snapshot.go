@@ -0,0 +1,223 @@
+package stats
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// routeStatsSnapshot is the JSON-serializable form of a RouteStats
+// entry, keyed by route since RouteStats itself is stored in a map.
+type routeStatsSnapshot struct {
+	Route     string            `json:"route"`
+	Stats     RouteStats        `json:"stats"`
+	Histogram histogramSnapshot `json:"histogram"`
+}
+
+// statusCountSnapshot is the JSON-serializable form of a
+// requestsByStatus entry. requestsByStatus is persisted as a slice
+// rather than a map because its key, statusLabel, is a struct and
+// encoding/json can't marshal struct-keyed maps.
+type statusCountSnapshot struct {
+	Route  string `json:"route"`
+	Method string `json:"method"`
+	Status int    `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+// routeLatencySnapshot is the JSON-serializable form of a
+// latenciesByRoute entry, persisted as a slice for the same reason as
+// statusCountSnapshot.
+type routeLatencySnapshot struct {
+	Route     string            `json:"route"`
+	Method    string            `json:"method"`
+	Histogram histogramSnapshot `json:"histogram"`
+}
+
+// snapshotData is the subset of Stats that Snapshot persists and
+// Restore loads back. RouteStats and latenciesByRoute persist their
+// backing histograms via histogramSnapshot so percentiles stay accurate
+// across a restart; SlowRoutes keeps only its already-computed P50-P99
+// fields, so its histogram (and therefore future percentile updates)
+// rebuilds from scratch after a restore.
+type snapshotData struct {
+	Uptime            time.Time
+	ResponseCounts    map[string]int
+	TotalResponseTime time.Time
+	BadRoutes         map[string]int
+	SlowRoutes        map[string]SlowRoutesData
+	TimeoutRoutes     map[string]int
+	TotalCount        int64
+	TotalErrors       int64
+	RouteStats        []routeStatsSnapshot
+	RequestsByStatus  []statusCountSnapshot
+	LatenciesByRoute  []routeLatencySnapshot
+}
+
+// Snapshot serializes the current counters as JSON to w. Pair it with
+// Restore to preserve long-running trend data across a process restart.
+func (mw *Stats) Snapshot(w io.Writer) error {
+	mw.mu.RLock()
+	snap := snapshotData{
+		Uptime:            mw.Uptime,
+		ResponseCounts:    mw.ResponseCounts,
+		TotalResponseTime: mw.TotalResponseTime,
+		BadRoutes:         mw.BadRoutes,
+		SlowRoutes:        mw.SlowRoutes,
+		TimeoutRoutes:     mw.TimeoutRoutes,
+		TotalCount:        atomic.LoadInt64(&mw.totalCount),
+		TotalErrors:       atomic.LoadInt64(&mw.totalErrors),
+	}
+	for route, rs := range mw.RouteStats {
+		snap.RouteStats = append(snap.RouteStats, routeStatsSnapshot{
+			Route:     route,
+			Stats:     rs,
+			Histogram: rs.histogram.snapshot(),
+		})
+	}
+	for label, count := range mw.requestsByStatus {
+		snap.RequestsByStatus = append(snap.RequestsByStatus, statusCountSnapshot{
+			Route:  label.Route,
+			Method: label.Method,
+			Status: label.Status,
+			Count:  count,
+		})
+	}
+	for label, hist := range mw.latenciesByRoute {
+		snap.LatenciesByRoute = append(snap.LatenciesByRoute, routeLatencySnapshot{
+			Route:     label.Route,
+			Method:    label.Method,
+			Histogram: hist.snapshot(),
+		})
+	}
+	mw.mu.RUnlock()
+
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// Restore replaces the current counters with ones previously written by
+// Snapshot. It's meant to be called once, right after construction and
+// before traffic starts flowing.
+func (mw *Stats) Restore(r io.Reader) error {
+	var snap snapshotData
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return err
+	}
+	if snap.ResponseCounts == nil {
+		snap.ResponseCounts = map[string]int{}
+	}
+	if snap.BadRoutes == nil {
+		snap.BadRoutes = map[string]int{}
+	}
+	if snap.SlowRoutes == nil {
+		snap.SlowRoutes = map[string]SlowRoutesData{}
+	}
+	if snap.TimeoutRoutes == nil {
+		snap.TimeoutRoutes = map[string]int{}
+	}
+
+	routeStats := map[string]RouteStats{}
+	for _, entry := range snap.RouteStats {
+		rs := entry.Stats
+		rs.histogram = entry.Histogram.restore()
+		routeStats[entry.Route] = rs
+	}
+
+	requestsByStatus := map[statusLabel]int64{}
+	for _, entry := range snap.RequestsByStatus {
+		label := statusLabel{routeLabel: routeLabel{Route: entry.Route, Method: entry.Method}, Status: entry.Status}
+		requestsByStatus[label] = entry.Count
+	}
+
+	latenciesByRoute := map[routeLabel]*latencyHistogram{}
+	for _, entry := range snap.LatenciesByRoute {
+		hist := entry.Histogram.restore()
+		latenciesByRoute[routeLabel{Route: entry.Route, Method: entry.Method}] = &hist
+	}
+
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	mw.Uptime = snap.Uptime
+	mw.ResponseCounts = snap.ResponseCounts
+	mw.TotalResponseTime = snap.TotalResponseTime
+	mw.BadRoutes = snap.BadRoutes
+	mw.SlowRoutes = snap.SlowRoutes
+	mw.TimeoutRoutes = snap.TimeoutRoutes
+	mw.RouteStats = routeStats
+	mw.requestsByStatus = requestsByStatus
+	mw.latenciesByRoute = latenciesByRoute
+	atomic.StoreInt64(&mw.totalCount, snap.TotalCount)
+	atomic.StoreInt64(&mw.totalErrors, snap.TotalErrors)
+
+	// Register the restored routes with a fresh LRU so WithMaxRoutes
+	// keeps applying to them; the snapshot carries no recency order, so
+	// which restored routes are evicted first is arbitrary.
+	mw.routeLRU = newRouteLRU(mw.maxRoutes)
+	for route := range mw.RouteStats {
+		if route == otherRouteKey {
+			continue
+		}
+		if evict, shouldEvict := mw.routeLRU.touch(route); shouldEvict {
+			mw.evictRouteToOther(evict)
+		}
+	}
+
+	return nil
+}
+
+// WithSnapshotFile configures Stats to load counters from path on
+// construction (if the file exists) and to overwrite it with a fresh
+// Snapshot every interval and on Close, so operators can preserve
+// statistics across deploys without standing up external aggregation.
+func WithSnapshotFile(path string, interval time.Duration) Option {
+	return func(s *Stats) {
+		s.snapshotFile = path
+		s.snapshotInterval = interval
+	}
+}
+
+func (mw *Stats) loadSnapshotFile() {
+	f, err := os.Open(mw.snapshotFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if err := mw.Restore(f); err != nil && mw.Logger != nil {
+		mw.Logger.Printf("stats: failed to restore snapshot from %s: %v", mw.snapshotFile, err)
+	}
+}
+
+func (mw *Stats) writeSnapshotFile() {
+	f, err := os.Create(mw.snapshotFile)
+	if err != nil {
+		if mw.Logger != nil {
+			mw.Logger.Printf("stats: failed to open snapshot file %s: %v", mw.snapshotFile, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	if err := mw.Snapshot(f); err != nil && mw.Logger != nil {
+		mw.Logger.Printf("stats: failed to write snapshot to %s: %v", mw.snapshotFile, err)
+	}
+}
+
+func (mw *Stats) runSnapshotLoop() {
+	defer close(mw.snapshotDone)
+
+	ticker := time.NewTicker(mw.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-mw.snapshotStop:
+			return
+		case <-ticker.C:
+			mw.writeSnapshotFile()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package stats
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestoreRoundTrip(t *testing.T) {
+	src := newStats(
+		WithLatencyThreshold(10*time.Millisecond),
+		WithTimeoutThreshold(time.Second),
+	)
+
+	start := time.Now().Add(-20 * time.Millisecond)
+	src.record(start, "/users", "GET", 200, 128)
+	src.record(start, "/users", "GET", 500, 64)
+	src.record(start, "/orders", "POST", 200, 256)
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	dst := newStats()
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	if dst.totalCount != src.totalCount {
+		t.Errorf("totalCount = %d, want %d", dst.totalCount, src.totalCount)
+	}
+	if dst.totalErrors != src.totalErrors {
+		t.Errorf("totalErrors = %d, want %d", dst.totalErrors, src.totalErrors)
+	}
+	if dst.BadRoutes["/users"] != src.BadRoutes["/users"] {
+		t.Errorf("BadRoutes[/users] = %d, want %d", dst.BadRoutes["/users"], src.BadRoutes["/users"])
+	}
+
+	for route, want := range src.RouteStats {
+		got, ok := dst.RouteStats[route]
+		if !ok {
+			t.Fatalf("RouteStats[%q] missing after restore", route)
+		}
+		if got.Count != want.Count || got.Bytes != want.Bytes {
+			t.Errorf("RouteStats[%q] = %+v, want %+v", route, got, want)
+		}
+		if got.P99 != want.P99 {
+			t.Errorf("RouteStats[%q].P99 = %v, want %v", route, got.P99, want.P99)
+		}
+		if got.histogram.total != want.histogram.total {
+			t.Errorf("RouteStats[%q].histogram.total = %d, want %d", route, got.histogram.total, want.histogram.total)
+		}
+	}
+
+	for label, want := range src.requestsByStatus {
+		got, ok := dst.requestsByStatus[label]
+		if !ok || got != want {
+			t.Errorf("requestsByStatus[%+v] = %d, want %d", label, got, want)
+		}
+	}
+
+	for label, want := range src.latenciesByRoute {
+		got, ok := dst.latenciesByRoute[label]
+		if !ok || got.total != want.total {
+			t.Errorf("latenciesByRoute[%+v] missing or mismatched total", label)
+		}
+	}
+}
+
+func TestRestoreOfEmptySnapshotLeavesUsableMaps(t *testing.T) {
+	dst := newStats()
+	if err := dst.Restore(&bytes.Buffer{}); err == nil {
+		t.Fatalf("Restore of empty input should fail to decode, got nil error")
+	}
+
+	var buf bytes.Buffer
+	src := newStats()
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	// Maps should be non-nil and safe to write into after restoring a
+	// snapshot taken from a Stats with no traffic recorded yet.
+	dst.BadRoutes["/x"] = 1
+	dst.RouteStats["/x"] = RouteStats{Count: 1}
+}
@@ -3,10 +3,11 @@ package stats
 import (
 	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
-	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,33 +26,122 @@ type Stats struct {
 	Latency           time.Duration
 	TimeoutLimit      time.Duration
 	GetKey            reformatURL
+	latencies         latencyHistogram
+	clock             func() time.Time
+	totalCount        int64
+	totalErrors       int64
+	rate              *rateCalculator
+	requestsByStatus  map[statusLabel]int64
+	latenciesByRoute  map[routeLabel]*latencyHistogram
+	snapshotFile      string
+	snapshotInterval  time.Duration
+	snapshotStop      chan struct{}
+	snapshotDone      chan struct{}
+	RouteStats        map[string]RouteStats
+	maxRoutes         int
+	routeLRU          *routeLRU
+	sampleRate        float64
+}
+
+// routeLabel identifies the route/method combination a per-route
+// latency histogram is kept for.
+type routeLabel struct {
+	Route  string
+	Method string
+}
+
+// statusLabel identifies the route/method/status combination the
+// http_requests_total counter is reported under.
+type statusLabel struct {
+	routeLabel
+	Status int
 }
 
 type SlowRoutesData struct {
 	Count   int
 	AvgTime time.Duration
 	MaxTime time.Duration
+	P50     time.Duration
+	P90     time.Duration
+	P95     time.Duration
+	P99     time.Duration
+
+	histogram latencyHistogram
 }
 
+// mergeSlowRoutesData folds src into dst, used when WithMaxRoutes
+// evicts a route's SlowRoutesData into the "other" bucket.
+func mergeSlowRoutesData(dst *SlowRoutesData, src SlowRoutesData) {
+	if src.Count == 0 {
+		return
+	}
+
+	totalCount := dst.Count + src.Count
+	if dst.Count == 0 {
+		dst.AvgTime = src.AvgTime
+	} else {
+		dst.AvgTime = time.Duration((int64(dst.AvgTime)*int64(dst.Count) + int64(src.AvgTime)*int64(src.Count)) / int64(totalCount))
+	}
+	if src.MaxTime > dst.MaxTime {
+		dst.MaxTime = src.MaxTime
+	}
+	dst.Count = totalCount
+
+	dst.histogram.merge(src.histogram)
+	dst.P50 = dst.histogram.percentile(0.50)
+	dst.P90 = dst.histogram.percentile(0.90)
+	dst.P95 = dst.histogram.percentile(0.95)
+	dst.P99 = dst.histogram.percentile(0.99)
+}
+
+// New constructs a Stats with the original positional signature. It is
+// kept as a back-compat shim for callers that predate the With* options
+// below and NewWithOptions. Unlike NewWithOptions, it does not start the
+// rate calculator goroutine, since callers using this constructor
+// predate Close and would never stop it; RPS/ErrorRPS in Data are left
+// empty as a result. Callers that want rate reporting should switch to
+// NewWithOptions and call Close when done.
 func New(logger *log.Logger, allowedLatency time.Duration, timeout time.Duration, fn reformatURL) *Stats {
-	stats := &Stats{
-		Uptime:            time.Now(),
-		Pid:               os.Getpid(),
-		ResponseCounts:    map[string]int{},
-		TotalResponseTime: time.Time{},
-		BadRoutes:         map[string]int{},
-		SlowRoutes:        map[string]SlowRoutesData{},
-		TimeoutRoutes:     map[string]int{},
-		Logger:            logger,
-		Latency:           allowedLatency,
-		TimeoutLimit:      timeout,
+	opts := []Option{
+		WithLatencyThreshold(allowedLatency),
+		WithTimeoutThreshold(timeout),
+	}
+	if logger != nil {
+		opts = append(opts, WithLogger(logger))
 	}
 	//This function can be used to properly group the routes (for example - cases where variable parameters exist in URLs)
 	if fn != nil {
-		stats.GetKey = fn
+		opts = append(opts, WithKeyFunc(fn))
 	}
 
-	return stats
+	return newStats(opts...)
+}
+
+// Close stops the background goroutines started by NewWithOptions (the
+// rate calculator and, if configured via WithSnapshotFile, the periodic
+// snapshot writer). It writes one last snapshot before returning so the
+// file on disk reflects state right up to shutdown.
+func (mw *Stats) Close() error {
+	if mw.rate != nil {
+		mw.rate.stopAndWait()
+	}
+	if mw.snapshotStop != nil {
+		close(mw.snapshotStop)
+		<-mw.snapshotDone
+	}
+	if mw.snapshotFile != "" {
+		mw.writeSnapshotFile()
+	}
+	return nil
+}
+
+// now returns the current time, using the clock supplied via
+// WithClock if one was configured.
+func (mw *Stats) now() time.Time {
+	if mw.clock != nil {
+		return mw.clock()
+	}
+	return time.Now()
 }
 
 // Negroni compatible interface
@@ -67,28 +157,41 @@ func (mw *Stats) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Han
 			w.WriteHeader(http.StatusInternalServerError)
 			stack := make([]byte, 1024*8)
 			stack = stack[:runtime.Stack(stack, false)]
-			mw.EndWithStatus(beginning, currentRoute, http.StatusInternalServerError)
+			mw.End(beginning, Options{Route: currentRoute, Method: r.Method, StatusCode: http.StatusInternalServerError})
 			f := time.Now().UTC().String() + "PANIC: %s\n%s"
 			mw.Logger.Printf(f, err, stack)
 		} else {
-			mw.EndWithStatus(beginning, currentRoute, recorder.Status())
+			mw.End(beginning, Options{Route: currentRoute, Method: r.Method, StatusCode: recorder.Status(), Size: recorder.Size()})
 		}
 	}(currentRoute)
 	next(recorder, r)
 }
 
 func (mw *Stats) Begin(w http.ResponseWriter) (time.Time, Recorder) {
-	start := time.Now()
-
-	writer := &RecorderResponseWriter{w, 200, 0}
+	return mw.now(), NewRecorder(w)
+}
 
-	return start, writer
+// End reports the outcome of a request described by opt. It is the
+// entry point for callers that don't go through the Negroni-compatible
+// ServeHTTP, e.g. net/http, chi, gin, or fasthttp handlers that wrap
+// their ResponseWriter in a Recorder (see NewRecorder) and call End
+// once the handler returns.
+func (mw *Stats) End(start time.Time, opt Options) {
+	mw.record(start, opt.Route, opt.Method, opt.StatusCode, opt.Size)
 }
 
+// EndWithStatus records the outcome of a request against currentRoute.
+// It predates Options and doesn't carry a method label or size; callers
+// that want those should use End instead.
 func (mw *Stats) EndWithStatus(start time.Time, currentRoute string, status int) {
-	end := time.Now()
+	mw.record(start, currentRoute, "", status, 0)
+}
+
+func (mw *Stats) record(start time.Time, currentRoute, method string, status, size int) {
+	end := mw.now()
 
 	responseTime := end.Sub(start)
+	sampled := mw.sampleRate >= 1 || rand.Float64() < mw.sampleRate
 
 	mw.mu.Lock()
 
@@ -98,23 +201,50 @@ func (mw *Stats) EndWithStatus(start time.Time, currentRoute string, status int)
 
 	mw.ResponseCounts[statusCode]++
 	mw.TotalResponseTime = mw.TotalResponseTime.Add(responseTime)
+	mw.latencies.observe(responseTime)
+	atomic.AddInt64(&mw.totalCount, 1)
+
+	route := routeLabel{Route: currentRoute, Method: method}
+	mw.requestsByStatus[statusLabel{routeLabel: route, Status: status}]++
+	hist, ok := mw.latenciesByRoute[route]
+	if !ok {
+		hist = &latencyHistogram{}
+		mw.latenciesByRoute[route] = hist
+	}
+	hist.observe(responseTime)
+
+	if mw.routeLRU != nil {
+		if evict, shouldEvict := mw.routeLRU.touch(currentRoute); shouldEvict && evict != currentRoute {
+			mw.evictRouteToOther(evict)
+		}
+	}
+	rs := mw.RouteStats[currentRoute]
+	rs.record(status, size, responseTime, sampled)
+	mw.RouteStats[currentRoute] = rs
+
 	if status >= http.StatusInternalServerError {
 		mw.BadRoutes[currentRoute] = mw.BadRoutes[currentRoute] + 1
+		atomic.AddInt64(&mw.totalErrors, 1)
 	}
 	if responseTime > mw.TimeoutLimit {
 		mw.TimeoutRoutes[currentRoute] = mw.TimeoutRoutes[currentRoute] + 1
 	} else if responseTime > mw.Latency {
-		if _, ok := mw.SlowRoutes[currentRoute]; !ok {
-			mw.SlowRoutes[currentRoute] = SlowRoutesData{Count: 1, AvgTime: responseTime, MaxTime: responseTime}
+		srd, ok := mw.SlowRoutes[currentRoute]
+		if !ok {
+			srd = SlowRoutesData{Count: 1, AvgTime: responseTime, MaxTime: responseTime}
 		} else {
-			srd := mw.SlowRoutes[currentRoute]
 			if responseTime > srd.MaxTime {
 				srd.MaxTime = responseTime
 			}
 			srd.AvgTime = ((srd.AvgTime * time.Duration(srd.Count)) + responseTime) / (time.Duration(srd.Count + 1))
 			srd.Count += 1
-			mw.SlowRoutes[currentRoute] = srd
 		}
+		srd.histogram.observe(responseTime)
+		srd.P50 = srd.histogram.percentile(0.50)
+		srd.P90 = srd.histogram.percentile(0.90)
+		srd.P95 = srd.histogram.percentile(0.95)
+		srd.P99 = srd.histogram.percentile(0.99)
+		mw.SlowRoutes[currentRoute] = srd
 	}
 }
 
@@ -130,9 +260,22 @@ type data struct {
 	TotalResponseTimeSec   float64                   `json:"total_response_time_sec"`
 	AverageResponseTime    string                    `json:"average_response_time"`
 	AverageResponseTimeSec float64                   `json:"average_response_time_sec"`
+	P50ResponseTime        string                    `json:"p50_response_time"`
+	P50ResponseTimeSec     float64                   `json:"p50_response_time_sec"`
+	P90ResponseTime        string                    `json:"p90_response_time"`
+	P90ResponseTimeSec     float64                   `json:"p90_response_time_sec"`
+	P95ResponseTime        string                    `json:"p95_response_time"`
+	P95ResponseTimeSec     float64                   `json:"p95_response_time_sec"`
+	P99ResponseTime        string                    `json:"p99_response_time"`
+	P99ResponseTimeSec     float64                   `json:"p99_response_time_sec"`
 	BadRoutes              map[string]int            `json:"bad_routes"`
 	SlowRoutes             map[string]SlowRoutesData `json:"slow_routes"`
-	TimeoutRoutes          map[string]int            `json:timeout_routes`
+	TimeoutRoutes          map[string]int            `json:"timeout_routes"`
+	// RPS and ErrorRPS report requests/sec and 5xx/sec over the windows
+	// in rateWindows: 10s, 1m, 5m, 15m, 30m, 60m, in that order.
+	RPS        []float64             `json:"rps"`
+	ErrorRPS   []float64             `json:"error_rps"`
+	RouteStats map[string]RouteStats `json:"route_stats"`
 }
 
 func (mw *Stats) Data() *data {
@@ -156,6 +299,21 @@ func (mw *Stats) Data() *data {
 		averageResponseTime = time.Duration(avgNs)
 	}
 
+	p50ResponseTime := mw.latencies.percentile(0.50)
+	p90ResponseTime := mw.latencies.percentile(0.90)
+	p95ResponseTime := mw.latencies.percentile(0.95)
+	p99ResponseTime := mw.latencies.percentile(0.99)
+
+	var rps, errorRps []float64
+	if mw.rate != nil {
+		rps, errorRps = mw.rate.rates()
+	}
+
+	routeStats := make(map[string]RouteStats, len(mw.RouteStats))
+	for route, rs := range mw.RouteStats {
+		routeStats[route] = rs
+	}
+
 	r := &data{
 		Pid:                    mw.Pid,
 		UpTime:                 uptime.String(),
@@ -168,9 +326,20 @@ func (mw *Stats) Data() *data {
 		TotalResponseTimeSec:   totalResponseTime.Seconds(),
 		AverageResponseTime:    averageResponseTime.String(),
 		AverageResponseTimeSec: averageResponseTime.Seconds(),
+		P50ResponseTime:        p50ResponseTime.String(),
+		P50ResponseTimeSec:     p50ResponseTime.Seconds(),
+		P90ResponseTime:        p90ResponseTime.String(),
+		P90ResponseTimeSec:     p90ResponseTime.Seconds(),
+		P95ResponseTime:        p95ResponseTime.String(),
+		P95ResponseTimeSec:     p95ResponseTime.Seconds(),
+		P99ResponseTime:        p99ResponseTime.String(),
+		P99ResponseTimeSec:     p99ResponseTime.Seconds(),
 		BadRoutes:              mw.BadRoutes,
 		SlowRoutes:             mw.SlowRoutes,
 		TimeoutRoutes:          mw.TimeoutRoutes,
+		RPS:                    rps,
+		ErrorRPS:               errorRps,
+		RouteStats:             routeStats,
 	}
 
 	mw.mu.RUnlock()